@@ -26,11 +26,24 @@
 #       ReplaceComponent (Role, ComponentID, CarID)     MANUFACTURE         ONLY
 #       RecallComponent (Role, ComponentID)             MANUFACTURE         ONLY
 #       CreateCar (Role, CarID)                         MANUFACTURE         ONLY
+#       AddComponentsBatch(Role, ComponentIDsJSON)      Supplier            ONLY
+#       MigrateComponentIDs (Role)                      MANUFACTURE         ONLY
 #   
 #   QUERY
 #
 #       QueryCar (CarID)                                                    ANYONE
 #       QueryComponent (ComponentID)                                        ANYONE
+#       QueryComponentsByCarID (CarID)                                      ANYONE
+#       GetComponentStatus (ComponentID)                                    ANYONE
+#       GetComponentsByOwnerPaginated (Owner, PageSize, Bookmark)           ANYONE
+#       VerifyComponentValidity (ComponentID)                               ANYONE
+#       QueryComponentRange (StartComponentID, EndComponentID)              ANYONE
+#       QueryRetiredComponents ()                                           ANYONE
+#       QueryComponentCountByRole ()                                        ANYONE
+#       QueryComponentUtilization ()                                        ANYONE
+#       ReadCar (CarID, ResolveComponents)                                  ANYONE
+#       QueryComponentJourney (ComponentID)                                 ANYONE
+#       QueryComponentChanges (ComponentID)                                 ANYONE
 #   
 ############################################################
 
@@ -62,6 +75,10 @@ CORE_PEER_ADDRESS=peer:7052 CORE_CHAINCODE_ID_NAME=CARcc:0 ./CARcc
 peer chaincode install -p chaincodedev/chaincode/CARcc -n CARcc -v 0
 # Instantiate the chaincode
 peer chaincode instantiate -n CARcc -v 0 -c '{"Args":[]}' -C myc
+# Or with a ComponentID length other than 9 (set once, kept on upgrade,
+# and refused on upgrade once components exist with the default length).
+# The first arg is the function name, so '{"Args":["12"]}' would be ignored
+#	$ peer chaincode instantiate -n CARcc -v 0 -c '{"Args":["init","12"]}' -C myc
 
 # Starting the invoke and query test
 #
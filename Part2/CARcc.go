@@ -21,6 +21,7 @@ import (
     "strconv"
     "strings"
     "errors"
+    "time"
 
     "github.com/hyperledger/fabric/core/chaincode/shim"
     "github.com/hyperledger/fabric/protos/peer"
@@ -69,6 +70,16 @@ type Creator struct {
 
 }
 
+// Length of the ComponentID if nothing is configured at instantiation
+const DefaultIDLength = 9
+
+// Composite key (object type and attribute) that stores the configured
+// ComponentID length. A composite key can't collide with a CarID or a
+// ComponentID, and it is never returned by GetStateByRange
+const ConfigObjectType = "config"
+
+const IDLengthKey = "IDLength"
+
 /*
     #############################################################
     #############################################################
@@ -80,10 +91,86 @@ type Creator struct {
 /*
     This function is called when this chaincode is instantiated.
     We have a separate function for ledger instantiation: see initLedger()
+
+    @args[0]:   (optional) the ComponentID length, 9 if not given. It comes
+                after the function name: {"Args":["init","12"]}, and can't be
+                changed by an upgrade once it is set or once any component
+                has been added with the default length
+
 */
 func (s *SmartContract) Init(stub shim.ChaincodeStubInterface) peer.Response {
     
-    // No action, because there is no components at the very beginning
+    // There is no components at the very beginning, we only record
+    // the ComponentID length if the instantiator asked for one
+    _, args := stub.GetFunctionAndParameters()
+
+    if len(args) == 0 {
+
+        return shim.Success(nil)
+
+    } else if len(args) > 1 {
+
+        return shim.Error("Incorrect number of arguments: expect at most 1.")
+
+    }
+
+    length, err := strconv.Atoi(args[0])
+
+    if err != nil || length <= 0 {
+
+        return shim.Error("Incorrect ID length: expect a positive integer.")
+
+    }
+
+    // Init runs again on every upgrade: changing the length then would make
+    // every existing component fail the ID check, so it can only be set once
+    lengthKey, err := stub.CreateCompositeKey(ConfigObjectType, []string{IDLengthKey})
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    lengthAsBytes, err := stub.GetState(lengthKey)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    } else if len(lengthAsBytes) != 0 && string(lengthAsBytes) != strconv.Itoa(length) {
+
+        return shim.Error("Incorrect ID length: already set to " + string(lengthAsBytes) + ", cannot be changed.")
+
+    }
+
+    // Nothing stored means the default length is in use, which is just as
+    // fixed as soon as a component has been added with it
+    if len(lengthAsBytes) == 0 && length != DefaultIDLength {
+
+        ComponentIDs, _, err := GetAllComponents(stub)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        } else if len(ComponentIDs) != 0 {
+
+            return shim.Error("Incorrect ID length: components already use the default length " + strconv.Itoa(DefaultIDLength) + ", cannot be changed.")
+
+        }
+
+    }
+
+    err = stub.PutState(lengthKey, []byte(strconv.Itoa(length)))
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    fmt.Println("[+] ComponentID length set to", length)
 
     return shim.Success(nil)
 
@@ -116,6 +203,10 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 
 		return s.AddComponent(stub, args)
 
+	} else if fn == "AddComponentsBatch" {
+
+		return s.AddComponentsBatch(stub, args)
+
 	} else if fn == "TransferComponent" {
 
 		return s.TransferComponent(stub, args)
@@ -132,6 +223,10 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 
 		return s.RecallComponent(stub, args)
 
+	} else if fn == "MigrateComponentIDs" {
+
+		return s.MigrateComponentIDs(stub, args)
+
 	} else if fn == "InitLedger" {
 
         return s.InitLedger(stub)
@@ -144,9 +239,54 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 
         return s.QueryCar(stub, args)
 
+    } else if fn == "ReadCar" {
+
+        return s.ReadCar(stub, args)
+
     } else if fn == "QueryComponent" {
 
         return s.QueryComponent(stub, args)
+
+    } else if fn == "VerifyComponentValidity" {
+
+        return s.VerifyComponentValidity(stub, args)
+
+    } else if fn == "GetComponentStatus" {
+
+        return s.GetComponentStatus(stub, args)
+
+    } else if fn == "QueryComponentsByCarID" {
+
+        return s.QueryComponentsByCarID(stub, args)
+
+    } else if fn == "QueryComponentRange" {
+
+        return s.QueryComponentRange(stub, args)
+
+    } else if fn == "QueryRetiredComponents" {
+
+        return s.QueryRetiredComponents(stub)
+
+    } else if fn == "QueryComponentCountByRole" {
+
+        return s.QueryComponentCountByRole(stub)
+
+    } else if fn == "QueryComponentUtilization" {
+
+        return s.QueryComponentUtilization(stub)
+
+    } else if fn == "QueryComponentJourney" {
+
+        return s.QueryComponentJourney(stub, args)
+
+    } else if fn == "QueryComponentChanges" {
+
+        return s.QueryComponentChanges(stub, args)
+
+    } else if fn == "GetComponentsByOwnerPaginated" {
+
+        return s.GetComponentsByOwnerPaginated(stub, args)
+
     }
 
     return shim.Error("Invalid Smart Contract function name.")
@@ -192,7 +332,7 @@ func (s *SmartContract) InitLedger(stub shim.ChaincodeStubInterface) peer.Respon
     } 
 
     /*
-    List of ComponentID:
+    List of ComponentID (with the default 9-digit length):
         000000000
         000000001
         000000002
@@ -204,13 +344,21 @@ func (s *SmartContract) InitLedger(stub shim.ChaincodeStubInterface) peer.Respon
 
     var ComponentID string
 
+    IDLength, err := GetIDLength(stub)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
     for i < len(components) {
 
         fmt.Println("i = ", i, "component is", components[i])
 
         componentAsBytes, _ := json.Marshal(components[i])
 
-        ComponentID = "00000000" + strconv.Itoa(i)
+        ComponentID = strings.Repeat("0", IDLength - 1) + strconv.Itoa(i)
 
         stub.PutState(ComponentID, componentAsBytes)
 
@@ -241,7 +389,7 @@ func (s *SmartContract) InitLedger(stub shim.ChaincodeStubInterface) peer.Respon
 
     @stub:      the chaincode interface
     @args[0]:   the role of the function invoker
-    @args[1]:   ComponentID (9-digit unique string)
+    @args[1]:   ComponentID (unique digit string, 9 digits by default)
 
 */
 func (s *SmartContract) AddComponent(stub shim.ChaincodeStubInterface, args []string) peer.Response {
@@ -273,9 +421,9 @@ func (s *SmartContract) AddComponent(stub shim.ChaincodeStubInterface, args []st
     ComponentID := args[1]
 
     // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+        return shim.Error(err.Error())
 
     }
 
@@ -339,6 +487,123 @@ func (s *SmartContract) AddComponent(stub shim.ChaincodeStubInterface, args []st
 }
 
 
+/*
+
+    Add a batch of car components in one transaction
+
+    Every ComponentID is checked before anything is written, so the whole
+    batch is aborted on the first bad or already used ComponentID.
+
+    ONLY called by Supplier
+
+    @stub:      the chaincode interface
+    @args[0]:   the role of the function invoker
+    @args[1]:   JSON array of ComponentIDs, e.g. ["000000010", "000000011"]
+
+*/
+func (s *SmartContract) AddComponentsBatch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    /*
+        #############################################################
+        #################### Arguments Checking #####################
+        #############################################################
+    */
+
+    if len(args) != 2 {
+
+        return shim.Error("Incorrect number of argument: expect 2.")
+
+    }
+
+    // Get the first part of the input as the role of invoker
+    rolename    := args[0]
+
+    role        := strings.Split(args[0], ".")[0]
+
+    // Role checking: only can be called by supplier
+    if !strings.EqualFold(role, "Supplier") {
+
+        return shim.Error("Incorrect role: expect Supplier.")
+
+    }
+
+    ComponentIDs := []string{}
+
+    if err := json.Unmarshal([]byte(args[1]), &ComponentIDs); err != nil {
+
+        return shim.Error("Incorrect ComponentID list: expect a JSON array of strings.")
+
+    }
+
+    if len(ComponentIDs) == 0 {
+
+        return shim.Error("Incorrect ComponentID list: expect at least one ComponentID.")
+
+    }
+
+    /*
+        #############################################################
+        ####################### Main Function #######################
+        #############################################################
+    */
+
+    seen := map[string]bool{}
+
+    // Check all the ComponentIDs before writing any of them
+    for _, ComponentID := range ComponentIDs {
+
+        if err := checkComponentID(stub, ComponentID); err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        if seen[ComponentID] {
+
+            return shim.Error("The given ComponentID " + ComponentID + " is repeated in the batch.")
+
+        }
+
+        seen[ComponentID] = true
+
+        exist, err := stub.GetState(ComponentID)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        } else if exist != nil {
+
+            return shim.Error("The given ComponentID " + ComponentID + " is already used.")
+
+        }
+
+    }
+
+    // Supplier is the initial Owner of all the new components
+    component := CarComponent{false, rolename, ""}
+
+    componentAsBytes, _ := json.Marshal(component)
+
+    for _, ComponentID := range ComponentIDs {
+
+        err := stub.PutState(ComponentID, componentAsBytes)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+    }
+
+    fmt.Println("[+] Added", len(ComponentIDs), "components", ComponentIDs, "by", rolename)
+
+    // return peer success response
+    return shim.Success(nil)
+}
+
+
 /*
     #############################################################
     #############################################################
@@ -375,10 +640,10 @@ func (s *SmartContract) TransferComponent(stub shim.ChaincodeStubInterface, args
 
     ComponentID := args[2]
 
-     // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+    // Check component ID format
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+        return shim.Error(err.Error())
 
     }
 
@@ -485,9 +750,9 @@ func (s *SmartContract) MountComponent(stub shim.ChaincodeStubInterface, args []
     ComponentID := args[1]
 
     // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+        return shim.Error(err.Error())
 
     }
 
@@ -619,9 +884,9 @@ func (s *SmartContract) ReplaceComponent(stub shim.ChaincodeStubInterface, args
     ComponentID := args[1]
 
     // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+        return shim.Error(err.Error())
 
     }
 
@@ -646,10 +911,21 @@ func (s *SmartContract) ReplaceComponent(stub shim.ChaincodeStubInterface, args
     json.Unmarshal(carAsBytes, &car)
 
 
-    // Check if component already Retired
+    // Note: component is the new one, which replaces the old one on the car
+
+    // Check if the replacement component exists, otherwise it would be
+    // decoded as a fresh, non-Retired component and pass the checks below
+    if len(componentAsBytes) == 0 {
+
+        return shim.Error("The given replacement component is not found.")
+
+    }
+
+    // Check if the replacement component already Retired: a Retired
+    // component must never be mounted again
     if component.Retired {
 
-        return shim.Error("The given component is already Retired.")
+        return shim.Error("The given replacement component is already Retired.")
 
     }
 
@@ -658,7 +934,7 @@ func (s *SmartContract) ReplaceComponent(stub shim.ChaincodeStubInterface, args
 
         return shim.Error("The given component is already mounted.")
 
-    }   // note: component is the new one
+    }
 
     // Check if this car is properly mounted with some comonent
     if strings.EqualFold(car.ComponentID, "") {
@@ -677,8 +953,6 @@ func (s *SmartContract) ReplaceComponent(stub shim.ChaincodeStubInterface, args
     json.Unmarshal(oldComponentAsBytes, &oldComponent)
 
     // Update the information of the new component and the car
-    component.Retired       = false
-
     component.Owner         = oldComponent.Owner
 
     component.CarID         = CarID
@@ -760,9 +1034,9 @@ func (s *SmartContract) RecallComponent(stub shim.ChaincodeStubInterface, args [
     ComponentID := args[1]
 
     // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+        return shim.Error(err.Error())
 
     }
 
@@ -815,27 +1089,38 @@ func (s *SmartContract) RecallComponent(stub shim.ChaincodeStubInterface, args [
 /*
     #############################################################
     #############################################################
-    ################## Check Car Component ######################
+    ################ Migrate Car Component IDs ##################
     #############################################################
     #############################################################
 */
 
+// Result of MigrateComponentIDs
+type MigrationReport struct {
+
+    Migrated    int         `json:"migrated"`
+
+    Skipped     []string    `json:"skipped"`   // malformed keys we could not fix
+
+}
 
 /*
 
-    Returns the validity of this component: true if valide, false otherwise
-    This function is similar to a helper function, and can only be called
-    by other functions with "role" and caller equals to "Car", not any invokers.
+    Repair components written under malformed keys, e.g. "12345" instead
+    of "000012345". Every component whose key is a digit string shorter
+    than the configured ID length is rewritten under the zero-padded key,
+    every car pointing to the old key is pointed to the new key, and the
+    old key is deleted. Malformed keys that can't be padded, or whose padded key
+    is already used, are left untouched and reported as skipped.
 
-    @handler:   Car struct pointer
-    @stub:      The chaincode stub interface
-    @role:      the ROLE of the caller (must be Car)
-    
-    Returns (bool, error) types
+    ONLY Manufacture can run this function
 
-*/
-func (car *Car) CheckComponent(stub shim.ChaincodeStubInterface, role string) (bool, error) {
+    @stub:      the chaincode interface
+    @args[0]:   ROLE
+
+    Returns a JSON object of {migrated, skipped}
 
+*/
+func (s *SmartContract) MigrateComponentIDs(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 
     /*
         #############################################################
@@ -843,9 +1128,21 @@ func (car *Car) CheckComponent(stub shim.ChaincodeStubInterface, role string) (b
         #############################################################
     */
 
-    if !strings.EqualFold(role, "Car") {
+    if len(args) != 1 {
 
-        return false, errors.New("Incorrect role, expect Car")
+        return shim.Error("Incorrect number of argument: expect 1.")
+
+    }
+
+    // Get the first part of the input as the role of invoker
+    rolename    := args[0]
+
+    role        := strings.Split(rolename, ".")[0]
+
+    // Role checking: only can be called by manufacture
+    if !strings.EqualFold(role, "Manufacture") {
+
+        return shim.Error("Incorrect role: expect Manufacture.")
 
     }
 
@@ -855,113 +1152,1484 @@ func (car *Car) CheckComponent(stub shim.ChaincodeStubInterface, role string) (b
         #############################################################
     */
 
-    ComponentID := car.ComponentID
+    resultsIterator, err := stub.GetStateByRange("", "")
 
-    if strings.EqualFold(ComponentID, "") {
+    if err != nil {
 
-        return false, errors.New("Got empty ComponentID from Car object")
+        return shim.Error(err.Error())
 
     }
 
-    componentAsBytes, _ := stub.GetState(ComponentID)
+    defer resultsIterator.Close()
 
-    component           := CarComponent{}
+    IDLength, err := GetIDLength(stub)
 
-    json.Unmarshal(componentAsBytes, &component)
+    if err != nil {
 
-    return (!component.Retired), nil
+        return shim.Error(err.Error())
 
-}
+    }
 
-/*
-    #############################################################
-    #############################################################
-    #################### My Helper Functions ############3#######
-    #############################################################
-    #############################################################
-*/
+    // Collect the malformed components first, and rewrite them after the scan
+    oldComponentIDs := []string{}
 
-/*
-    Check the ID format of car component: should be 9-digit string
-    
-    Return true if format is correct, and false otherwise
-*/
-func CheckIDFormat(ComponentID string) bool {
+    components      := []CarComponent{}
 
-    if len(ComponentID) != 9 {
+    // Collect the cars too, since a car may point to a malformed key even
+    // when the component doesn't record the car (e.g. after CreateCar)
+    carIDs          := []string{}
 
-        // check the length of the ComponentID is nine
-        return false
+    cars            := []Car{}
 
-    } else if _, err := strconv.Atoi(ComponentID); err != nil {
+    report := MigrationReport{Skipped: []string{}}
 
-        // check the ComponentID are all digits
-        return false
+    for resultsIterator.HasNext() {
 
-    } else {
+        queryResponse, err := resultsIterator.Next()
 
-        // now everything looks fine
-        return true
+        if err != nil {
 
-    }
+            return shim.Error(err.Error())
 
-}
+        }
 
+        if car, ok := isCarRecord(queryResponse.Value); ok {
 
-/*
+            carIDs  = append(carIDs, queryResponse.Key)
 
-    Creating a simple car onto the blockchain network (for test purpose)
+            cars    = append(cars, car)
 
-    ONLY Manufacture can run this function, because only it can MountComponent,
+            continue
 
-    which means it is the first point to record a new incoming car.
+        }
 
-    @args[0]: ROLE
-    @args[1]: ComponentID
-    @args[2]: CarID
+        if CheckIDFormatN(queryResponse.Key, IDLength) {
 
-*/
-func (s *SmartContract) CreateCar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+            continue
 
-    /*
+        }
+
+        // Only records with the component fields are components, others
+        // (cars, configuration) are not ours to migrate
+        component, ok := isComponentRecord(queryResponse.Value)
+
+        if !ok {
+
+            continue
+
+        }
+
+        oldComponentIDs = append(oldComponentIDs, queryResponse.Key)
+
+        components      = append(components, component)
+
+    }
+
+    // GetState doesn't see the writes made earlier in this transaction, so
+    // keep our own record of the padded keys already written by this call
+    written := map[string]bool{}
+
+    for i, oldComponentID := range oldComponentIDs {
+
+        // Only a short digit string can be fixed by zero padding
+        if len(oldComponentID) == 0 || len(oldComponentID) > IDLength {
+
+            report.Skipped = append(report.Skipped, oldComponentID)
+
+            continue
+
+        }
+
+        ComponentID := strings.Repeat("0", IDLength - len(oldComponentID)) + oldComponentID
+
+        if !CheckIDFormatN(ComponentID, IDLength) {
+
+            report.Skipped = append(report.Skipped, oldComponentID)
+
+            continue
+
+        }
+
+        exist, err := stub.GetState(ComponentID)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        } else if exist != nil || written[ComponentID] {
+
+            report.Skipped = append(report.Skipped, oldComponentID)
+
+            continue
+
+        }
+
+        written[ComponentID] = true
+
+        componentAsBytes, _ := json.Marshal(components[i])
+
+        err = stub.PutState(ComponentID, componentAsBytes)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        err = stub.DelState(oldComponentID)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        // Point every car on the old key to the new key
+        for j := range cars {
+
+            if cars[j].ComponentID != oldComponentID {
+
+                continue
+
+            }
+
+            cars[j].ComponentID = ComponentID
+
+            carAsBytes, _ := json.Marshal(cars[j])
+
+            err = stub.PutState(carIDs[j], carAsBytes)
+
+            if err != nil {
+
+                return shim.Error(err.Error())
+
+            }
+
+        }
+
+        fmt.Println("[+] Migrated", components[i], "from", oldComponentID, "to", ComponentID, "by", rolename)
+
+        report.Migrated = report.Migrated + 1
+
+    }
+
+    reportAsBytes, _ := json.Marshal(report)
+
+    fmt.Println("MigrateComponentIDs:", string(reportAsBytes))
+
+    return shim.Success(reportAsBytes)
+
+}
+
+
+/*
+    #############################################################
+    #############################################################
+    ################## Check Car Component ######################
+    #############################################################
+    #############################################################
+*/
+
+
+/*
+
+    Returns the validity of this component: true if valide, false otherwise
+    This function is similar to a helper function, and can only be called
+    by other functions with "role" and caller equals to "Car", not any invokers.
+
+    @handler:   Car struct pointer
+    @stub:      The chaincode stub interface
+    @role:      the ROLE of the caller (must be Car)
+    
+    Returns (bool, error) types
+
+*/
+func (car *Car) CheckComponent(stub shim.ChaincodeStubInterface, role string) (bool, error) {
+
+
+    /*
         #############################################################
         #################### Arguments Checking #####################
         #############################################################
     */
 
-    if len(args) != 3 {
+    if !strings.EqualFold(role, "Car") {
+
+        return false, errors.New("Incorrect role, expect Car")
+
+    }
+
+    /*
+        #############################################################
+        ####################### Main Function #######################
+        #############################################################
+    */
+
+    ComponentID := car.ComponentID
+
+    if strings.EqualFold(ComponentID, "") {
+
+        return false, errors.New("Got empty ComponentID from Car object")
+
+    }
+
+    componentAsBytes, _ := stub.GetState(ComponentID)
+
+    component           := CarComponent{}
+
+    json.Unmarshal(componentAsBytes, &component)
+
+    return (!component.Retired), nil
+
+}
+
+
+// Result of VerifyComponentValidity
+type ComponentValidity struct {
+
+    Valid       bool    `json:"valid"`
+
+    Reason      string  `json:"reason"`
+
+}
+
+/*
+
+    Client-facing check of one component: valid if it is not Retired
+    and currently mounted on a car. Unlike CheckComponent, there is no
+    "Car" role gate, so any client can call it.
+
+    Privilege:  ANYONE
+
+    @args[0]:   ComponentID
+
+    Returns a JSON object of {valid, reason}
+
+*/
+func (s *SmartContract) VerifyComponentValidity(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+
+        return shim.Error("Incorrect number of arguments, expecting 1")
+
+    }
+
+    ComponentID := args[0]
+
+    // Check component ID format
+    if err := checkComponentID(stub, ComponentID); err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    componentAsBytes, err := stub.GetState(ComponentID)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    // A car stored under a digit CarID is not a component
+    component, ok := isComponentRecord(componentAsBytes)
+
+    validity := ComponentValidity{Valid: false}
+
+    if !ok {
+
+        validity.Reason = "The given component is not found."
+
+    } else if component.Retired {
+
+        validity.Reason = "The given component is already Retired."
+
+    } else if strings.EqualFold(component.CarID, "") {
+
+        validity.Reason = "The given component is not mounted."
+
+    } else {
+
+        validity.Valid = true
+
+        validity.Reason = "The given component is mounted on car " + component.CarID + "."
+
+    }
+
+    validityAsBytes, _ := json.Marshal(validity)
+
+    fmt.Println("VerifyComponentValidity:", string(validityAsBytes))
+
+    return shim.Success(validityAsBytes)
+
+}
+
+/*
+    #############################################################
+    #############################################################
+    #################### My Helper Functions ############3#######
+    #############################################################
+    #############################################################
+*/
+
+/*
+    Check the ID format of car component: should be 9-digit string
+    
+    Return true if format is correct, and false otherwise
+*/
+func CheckIDFormat(ComponentID string) bool {
+
+    return CheckIDFormatN(ComponentID, DefaultIDLength)
+
+}
+
+
+/*
+    Check the ID format of car component: should be a digit string
+    with exactly the given length
+
+    Return true if format is correct, and false otherwise
+*/
+func CheckIDFormatN(ComponentID string, length int) bool {
+
+    if len(ComponentID) != length {
+
+        // check the length of the ComponentID is as expected
+        return false
+
+    }
+
+    for i := 0; i < len(ComponentID); i++ {
+
+        // check the ComponentID are all digits (no sign, no overflow)
+        if ComponentID[i] < '0' || ComponentID[i] > '9' {
+
+            return false
+
+        }
+
+    }
+
+    // now everything looks fine
+    return true
+
+}
+
+
+/*
+    Check the ID format of car component against the ComponentID length
+    configured at instantiation (see GetIDLength)
+
+    Return an error naming the ComponentID if the format is incorrect
+*/
+func checkComponentID(stub shim.ChaincodeStubInterface, ComponentID string) error {
+
+    IDLength, err := GetIDLength(stub)
+
+    if err != nil {
+
+        return err
+
+    }
+
+    if !CheckIDFormatN(ComponentID, IDLength) {
+
+        return errors.New("Incorrect ComponentID format for " + ComponentID + ": expect " + strconv.Itoa(IDLength) + "-digit string")
+
+    }
+
+    return nil
+
+}
+
+
+/*
+    Get the ComponentID length configured at instantiation (see Init)
+
+    Return DefaultIDLength if no length was configured, and an error if
+    the stored length can't be read
+*/
+func GetIDLength(stub shim.ChaincodeStubInterface) (int, error) {
+
+    lengthKey, err := stub.CreateCompositeKey(ConfigObjectType, []string{IDLengthKey})
+
+    if err != nil {
+
+        return 0, err
+
+    }
+
+    lengthAsBytes, err := stub.GetState(lengthKey)
+
+    if err != nil {
+
+        return 0, err
+
+    } else if len(lengthAsBytes) == 0 {
+
+        return DefaultIDLength, nil
+
+    }
+
+    length, err := strconv.Atoi(string(lengthAsBytes))
+
+    if err != nil || length <= 0 {
+
+        return 0, errors.New("Incorrect ID length stored on the ledger: " + string(lengthAsBytes))
+
+    }
+
+    return length, nil
+
+}
+
+
+/*
+
+    Creating a simple car onto the blockchain network (for test purpose)
+
+    ONLY Manufacture can run this function, because only it can MountComponent,
+
+    which means it is the first point to record a new incoming car.
+
+    @args[0]: ROLE
+    @args[1]: ComponentID
+    @args[2]: CarID
+
+*/
+func (s *SmartContract) CreateCar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    /*
+        #############################################################
+        #################### Arguments Checking #####################
+        #############################################################
+    */
+
+    if len(args) != 3 {
+
+        return shim.Error("Incorrect number of argument: expect 3.")
+
+    }
+
+    // Get the first part of the input as the role of invoker
+    role := strings.Split(args[0], ".")[0]
+
+    // Role checking: only can be called by supplier
+    if !strings.EqualFold(role, "Manufacture") {
+
+        return shim.Error("Incorrect role: expect Manufacture.")
+
+    }
+
+    ComponentID := args[1]
+
+    // Check component ID format
+    if err := checkComponentID(stub, ComponentID); err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    CarID := args[2]
+
+    // Recording this new car onto the blockchain
+    var car = Car{ComponentID: ComponentID}
+
+    carAsBytes, _ := json.Marshal(car)
+
+    err := stub.PutState(CarID, carAsBytes)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    fmt.Println("Created a car", car)
+
+    return shim.Success(nil)
+}
+
+/*
+
+    Query one car
+    @args[0]:   The CarID
+
+*/
+func (s *SmartContract) QueryCar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+
+        return shim.Error("Incorrect number of arguments, expecting 1")
+
+    }
+
+    CarID := args[0]
+
+    fmt.Println("Client trying to query car", CarID, "...")
+
+    // We don't need to Unmarshal because we will transfer it back to client as bytes
+    carAsBytes, err := stub.GetState(CarID)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    } else if len(carAsBytes) == 0 {
+
+        return shim.Error("QueryCar Error: CarID " + CarID + " not found")
+    }
+
+    fmt.Println("QueryCar:", carAsBytes)
+
+    return shim.Success(carAsBytes)
+
+}
+
+// Normalized view of one car returned by ReadCar
+type CarView struct {
+
+    CarID           string              `json:"carId"`
+
+    ComponentIDs    []string            `json:"componentIds"`
+
+    Owner           string              `json:"owner"`     // Owner of the mounted component
+
+    Components      *[]ComponentRecord  `json:"components,omitempty"`   // nil unless resolving
+
+}
+
+/*
+
+    Read one car as a normalized JSON object instead of the raw stored
+    bytes. A Car only records its mounted ComponentID, so the owner is
+    taken from the mounted component (empty if nothing is mounted).
+
+    Privilege:  ANYONE
+
+    @args[0]:   CarID
+    @args[1]:   (optional) "true" to also return the mounted components
+
+    Returns a JSON object of {carId, componentIds, owner, components},
+    where components is left out unless asked for
+
+*/
+func (s *SmartContract) ReadCar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 && len(args) != 2 {
+
+        return shim.Error("Incorrect number of arguments, expecting 1 or 2")
+
+    }
+
+    CarID := args[0]
+
+    resolve := false
+
+    if len(args) == 2 {
+
+        var err error
+
+        resolve, err = strconv.ParseBool(args[1])
+
+        if err != nil {
+
+            return shim.Error("Incorrect flag: expect true or false.")
+
+        }
+
+    }
+
+    fmt.Println("Client trying to read car", CarID, "...")
+
+    carAsBytes, err := stub.GetState(CarID)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    // A component or any other record stored under CarID is not a car
+    car, ok := isCarRecord(carAsBytes)
+
+    if !ok {
+
+        return shim.Error("ReadCar Error: CarID " + CarID + " not found")
+
+    }
+
+    view := CarView{CarID: CarID, ComponentIDs: []string{}}
+
+    // Point to an empty list when resolving is asked for, so components
+    // is always an array then, and left out otherwise
+    if resolve {
+
+        view.Components = &[]ComponentRecord{}
+
+    }
+
+    if !strings.EqualFold(car.ComponentID, "") {
+
+        view.ComponentIDs = append(view.ComponentIDs, car.ComponentID)
+
+        componentAsBytes, err := stub.GetState(car.ComponentID)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        if len(componentAsBytes) != 0 {
+
+            component := CarComponent{}
+
+            json.Unmarshal(componentAsBytes, &component)
+
+            view.Owner = component.Owner
+
+            if resolve {
+
+                *view.Components = append(*view.Components, ComponentRecord{car.ComponentID, component.Retired, component.Owner, component.CarID})
+
+            }
+
+        }
+
+    }
+
+    viewAsBytes, _ := json.Marshal(view)
+
+    fmt.Println("ReadCar:", string(viewAsBytes))
+
+    return shim.Success(viewAsBytes)
+
+}
+
+/*
+
+    Query one component by ComponentID
+    @args[0]: ComponentID
+
+*/
+func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+
+        return shim.Error("Incorrect number of arguments, expecting 1")
+
+    }
+
+    ComponentID := args[0]
+
+    // Check component ID format
+    if err := checkComponentID(stub, ComponentID); err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    fmt.Println("Client trying to query component", ComponentID, "...")
+
+    // We don't need to Unmarshal because we will transfer it back to client as bytes
+    componentAsBytes, err := stub.GetState(ComponentID)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    } else if len(componentAsBytes) == 0 {
+
+        return shim.Error("QueryComponent Error: ComponentID " + ComponentID + " not found")
+
+    }
+
+    fmt.Println("QueryComponent:", componentAsBytes)
+
+
+    return shim.Success(componentAsBytes)
+
+}
+
+
+// Normalized view of one component returned by GetComponentStatus
+type ComponentStatus struct {
+
+    ComponentID string  `json:"componentId"`
+
+    Owner       string  `json:"owner"`
+
+    Role        string  `json:"role"`     // ROLE_TYPE part of the Owner
+
+    Name        string  `json:"name"`     // ROLE_NAME part of the Owner
+
+    Retired     bool    `json:"retired"`
+
+    Mounted     bool    `json:"mounted"`  // true if CarID is not empty
+
+    CarID       string  `json:"carId"`
+
+}
+
+/*
+
+    Get the status of one component as a normalized JSON object, so
+    clients don't need to interpret the raw stored fields themselves
+
+    Privilege:  ANYONE
+
+    @args[0]:   ComponentID
+
+*/
+func (s *SmartContract) GetComponentStatus(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+
+        return shim.Error("Incorrect number of arguments, expecting 1")
+
+    }
+
+    ComponentID := args[0]
+
+    // Check component ID format
+    if err := checkComponentID(stub, ComponentID); err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    componentAsBytes, err := stub.GetState(ComponentID)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    // A car stored under a digit CarID is not a component
+    component, ok := isComponentRecord(componentAsBytes)
+
+    if !ok {
+
+        return shim.Error("GetComponentStatus Error: ComponentID " + ComponentID + " not found")
+
+    }
+
+    // Owner is in the format of "ROLE_TYPE.ROLE_NAME"
+    ownerParts := strings.SplitN(component.Owner, ".", 2)
+
+    status := ComponentStatus{
+
+        ComponentID:    ComponentID,
+
+        Owner:          component.Owner,
+
+        Role:           ownerParts[0],
+
+        Retired:        component.Retired,
+
+        Mounted:        component.CarID != "",
+
+        CarID:          component.CarID,
+
+    }
+
+    if len(ownerParts) == 2 {
+
+        status.Name = ownerParts[1]
+
+    }
+
+    statusAsBytes, _ := json.Marshal(status)
+
+    fmt.Println("GetComponentStatus:", string(statusAsBytes))
+
+    return shim.Success(statusAsBytes)
+
+}
+
+
+/*
+    Decode a stored value as a car component. A key in the ComponentID
+    format is not enough, since CreateCar accepts any CarID, so the value
+    must also carry the component fields ("Owner" and "retired").
+
+    Returns the component and true if the value is a component
+*/
+func isComponentRecord(value []byte) (CarComponent, bool) {
+
+    component := CarComponent{}
+
+    fields := map[string]interface{}{}
+
+    if json.Unmarshal(value, &fields) != nil {
+
+        return component, false
+
+    }
+
+    _, hasOwner     := fields["Owner"]
+
+    _, hasRetired   := fields["retired"]
+
+    if !hasOwner || !hasRetired {
+
+        return component, false
+
+    }
+
+    json.Unmarshal(value, &component)
+
+    return component, true
+
+}
+
+
+/*
+    Decode a stored value as a car. Cars are stored under any CarID, so
+    they are told apart by the value, which must carry the "ComponentID"
+    field.
+
+    Returns the car and true if the value is a car
+*/
+func isCarRecord(value []byte) (Car, bool) {
+
+    car := Car{}
+
+    fields := map[string]interface{}{}
+
+    if json.Unmarshal(value, &fields) != nil {
+
+        return car, false
+
+    }
+
+    if _, hasComponentID := fields["ComponentID"]; !hasComponentID {
+
+        return car, false
+
+    }
+
+    json.Unmarshal(value, &car)
+
+    return car, true
+
+}
+
+
+/*
+    Decode one world state entry as a car component. The key must be a
+    ComponentID of the given length, and the value a component record,
+    since cars can also be stored under a digit CarID.
+
+    Returns the component and true if the entry is a component
+*/
+func componentFromKV(key string, value []byte, IDLength int) (CarComponent, bool) {
+
+    if !CheckIDFormatN(key, IDLength) {
+
+        return CarComponent{}, false
+
+    }
+
+    return isComponentRecord(value)
+
+}
+
+
+/*
+    Helper function to query all components
+
+    Scan the whole world state and keep the entries whose key is a valid
+    ComponentID, so cars and other records are skipped.
+
+    Returns the ComponentIDs and their components in key order
+*/
+func GetAllComponents(stub shim.ChaincodeStubInterface) ([]string, []CarComponent, error) {
+
+    resultsIterator, err := stub.GetStateByRange("", "")
+
+    if err != nil {
+
+        return nil, nil, err
+
+    }
+
+    defer resultsIterator.Close()
+
+    IDLength, err := GetIDLength(stub)
+
+    if err != nil {
+
+        return nil, nil, err
+
+    }
+
+    ComponentIDs := []string{}
+
+    components := []CarComponent{}
+
+    for resultsIterator.HasNext() {
+
+        queryResponse, err := resultsIterator.Next()
+
+        if err != nil {
+
+            return nil, nil, err
+
+        }
+
+        // Skip cars and other non-component entries
+        component, ok := componentFromKV(queryResponse.Key, queryResponse.Value, IDLength)
+
+        if !ok {
+
+            continue
+
+        }
+
+        ComponentIDs = append(ComponentIDs, queryResponse.Key)
+
+        components = append(components, component)
+
+    }
+
+    return ComponentIDs, components, nil
+
+}
+
+
+// One version of a component in its history
+type ComponentVersion struct {
+
+    TxId        string  `json:"txId"`
+
+    Timestamp   string  `json:"timestamp"`
+
+    Owner       string  `json:"owner"`
+
+    CarID       string  `json:"carId"`
+
+    Retired     bool    `json:"retired"`
+
+    Deleted     bool    `json:"deleted,omitempty"`   // key deleted, e.g. by MigrateComponentIDs
+
+}
+
+/*
+    Helper function to get every version of one component from
+    GetHistoryForKey, in the order returned by the ledger
+
+    Returns the versions, empty if the component never existed
+*/
+func GetComponentHistory(stub shim.ChaincodeStubInterface, ComponentID string) ([]ComponentVersion, error) {
+
+    historyIterator, err := stub.GetHistoryForKey(ComponentID)
+
+    if err != nil {
+
+        return nil, err
+
+    }
+
+    defer historyIterator.Close()
+
+    versions := []ComponentVersion{}
+
+    for historyIterator.HasNext() {
+
+        modification, err := historyIterator.Next()
+
+        if err != nil {
+
+            return nil, err
+
+        }
+
+        timestamp := time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+
+        version := ComponentVersion{TxId: modification.TxId, Timestamp: timestamp.Format(time.RFC3339)}
+
+        if modification.IsDelete {
+
+            version.Deleted = true
+
+        } else {
+
+            component := CarComponent{}
+
+            json.Unmarshal(modification.Value, &component)
+
+            version.Owner   = component.Owner
+
+            version.CarID   = component.CarID
+
+            version.Retired = component.Retired
+
+        }
+
+        versions = append(versions, version)
+
+    }
+
+    return versions, nil
+
+}
+
+
+/*
+    #############################################################
+    #############################################################
+    ############### Query Components by CarID ###################
+    #############################################################
+    #############################################################
+*/
+
+/*
+
+    Query every component currently or historically mounted on a car.
+
+    The components mounted right now are found by scanning all components,
+    and the ones replaced off the car (now Retired, with an empty CarID)
+    are found in the history of the Car record.
+
+    Privilege:  ANYONE
+
+    @args[0]:   CarID
+
+    Returns a JSON array of {ComponentID, retired, Owner, carid}, where
+    carid is empty for the components replaced off the car
+
+*/
+func (s *SmartContract) QueryComponentsByCarID(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+
+        return shim.Error("Incorrect number of arguments, expecting 1")
+
+    }
+
+    CarID := args[0]
+
+    // An empty CarID would match every unmounted component
+    if strings.EqualFold(CarID, "") {
+
+        return shim.Error("Incorrect CarID: expect a non-empty string.")
+
+    }
+
+    fmt.Println("Client trying to query components of car", CarID, "...")
+
+    // Keep the ComponentIDs in the order we found them, without duplicates
+    ComponentIDs := []string{}
+
+    seen := map[string]bool{}
+
+    // Components that are mounted on the car right now
+    allComponentIDs, components, err := GetAllComponents(stub)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    for i, component := range components {
+
+        if component.CarID == CarID && !seen[allComponentIDs[i]] {
+
+            seen[allComponentIDs[i]] = true
+
+            ComponentIDs = append(ComponentIDs, allComponentIDs[i])
+
+        }
+
+    }
+
+    // Components that were mounted on the car in the past
+    historyIterator, err := stub.GetHistoryForKey(CarID)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    defer historyIterator.Close()
+
+    for historyIterator.HasNext() {
+
+        modification, err := historyIterator.Next()
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        if modification.IsDelete {
+
+            continue
+
+        }
+
+        car := Car{}
+
+        json.Unmarshal(modification.Value, &car)
+
+        if car.ComponentID != "" && !seen[car.ComponentID] {
+
+            seen[car.ComponentID] = true
+
+            ComponentIDs = append(ComponentIDs, car.ComponentID)
+
+        }
+
+    }
+
+    // Build the result with the latest state of every component
+    entries := []ComponentRecord{}
+
+    for _, ComponentID := range ComponentIDs {
+
+        componentAsBytes, err := stub.GetState(ComponentID)
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        } else if len(componentAsBytes) == 0 {
+
+            continue
+
+        }
+
+        component := CarComponent{}
+
+        json.Unmarshal(componentAsBytes, &component)
+
+        entries = append(entries, ComponentRecord{ComponentID, component.Retired, component.Owner, component.CarID})
+
+    }
+
+    entriesAsBytes, _ := json.Marshal(entries)
+
+    fmt.Println("QueryComponentsByCarID:", string(entriesAsBytes))
+
+    return shim.Success(entriesAsBytes)
+
+}
+
+
+/*
+    #############################################################
+    #############################################################
+    ############### Query a Range of Components #################
+    #############################################################
+    #############################################################
+*/
+
+/*
+
+    Query a contiguous block of components by ComponentID, both ends
+    included, e.g. "000000010" to "000000019"
+
+    Privilege:  ANYONE
+
+    @args[0]:   the first ComponentID of the range
+    @args[1]:   the last ComponentID of the range
+
+    Returns a JSON array of {ComponentID, retired, Owner, carid}
+
+*/
+func (s *SmartContract) QueryComponentRange(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 2 {
+
+        return shim.Error("Incorrect number of arguments, expecting 2")
+
+    }
+
+    startID := args[0]
+
+    endID   := args[1]
+
+    // Check component ID format of both ends
+    for _, ComponentID := range []string{startID, endID} {
+
+        if err := checkComponentID(stub, ComponentID); err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+    }
+
+    if startID > endID {
+
+        return shim.Error("Incorrect range: start ComponentID " + startID + " is after end ComponentID " + endID)
+
+    }
+
+    fmt.Println("Client trying to query components from", startID, "to", endID, "...")
+
+    // The end key of GetStateByRange is exclusive, so we extend it by
+    // the smallest possible suffix to include endID itself
+    resultsIterator, err := stub.GetStateByRange(startID, endID + "\x00")
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    defer resultsIterator.Close()
+
+    IDLength, err := GetIDLength(stub)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    records := []ComponentRecord{}
+
+    for resultsIterator.HasNext() {
+
+        queryResponse, err := resultsIterator.Next()
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        // Skip cars and other non-component entries inside the range
+        component, ok := componentFromKV(queryResponse.Key, queryResponse.Value, IDLength)
+
+        if !ok {
+
+            continue
+
+        }
+
+        records = append(records, ComponentRecord{queryResponse.Key, component.Retired, component.Owner, component.CarID})
+
+    }
+
+    recordsAsBytes, _ := json.Marshal(records)
+
+    fmt.Println("QueryComponentRange:", string(recordsAsBytes))
+
+    return shim.Success(recordsAsBytes)
+
+}
+
+
+/*
+    #############################################################
+    #############################################################
+    ############### Query Retired Components ####################
+    #############################################################
+    #############################################################
+*/
+
+// One entry of the QueryRetiredComponents result
+type RetiredComponentEntry struct {
+
+    ComponentID string  `json:"ComponentID"`
+
+    Owner       string  `json:"Owner"`
+
+    FormerCarID string  `json:"formerCarid"`   // empty if it was never mounted
+
+}
+
+/*
+
+    Query all the Retired components, for warranty and recall auditing.
+
+    Retiring a component clears its CarID, so the former CarID is the
+    last non-empty CarID found in the history of the component.
+
+    Privilege:  ANYONE
+
+    Returns a JSON array of {ComponentID, Owner, formerCarid}
+
+*/
+func (s *SmartContract) QueryRetiredComponents(stub shim.ChaincodeStubInterface) peer.Response {
+
+    fmt.Println("Client trying to query retired components ...")
+
+    ComponentIDs, components, err := GetAllComponents(stub)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    entries := []RetiredComponentEntry{}
+
+    for i, component := range components {
+
+        if !component.Retired {
+
+            continue
+
+        }
+
+        entry := RetiredComponentEntry{ComponentID: ComponentIDs[i], Owner: component.Owner}
+
+        versions, err := GetComponentHistory(stub, ComponentIDs[i])
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        for _, version := range versions {
+
+            if !version.Deleted && version.CarID != "" {
+
+                entry.FormerCarID = version.CarID
+
+            }
+
+        }
+
+        entries = append(entries, entry)
+
+    }
+
+    entriesAsBytes, _ := json.Marshal(entries)
+
+    fmt.Println("QueryRetiredComponents:", string(entriesAsBytes))
+
+    return shim.Success(entriesAsBytes)
+
+}
+
 
-        return shim.Error("Incorrect number of argument: expect 3.")
+/*
+    #############################################################
+    #############################################################
+    ############ Count Components by Owner Role #################
+    #############################################################
+    #############################################################
+*/
+
+/*
+
+    Count how many components each role currently owns. Retired
+    components are counted in a separate "retired" bucket instead of
+    under their Owner's role, and the known roles are always reported,
+    with zero if they hold nothing.
+
+    Privilege:  ANYONE
+
+    Returns a JSON map of role -> count, e.g.
+    {"Dealer":1,"Manufacture":2,"Supplier":2,"retired":1}
+
+*/
+func (s *SmartContract) QueryComponentCountByRole(stub shim.ChaincodeStubInterface) peer.Response {
+
+    fmt.Println("Client trying to count components by role ...")
+
+    _, components, err := GetAllComponents(stub)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
 
     }
 
-    // Get the first part of the input as the role of invoker
-    role := strings.Split(args[0], ".")[0]
+    knownRoles := []string{"Supplier", "Manufacture", "Dealer"}
 
-    // Role checking: only can be called by supplier
-    if !strings.EqualFold(role, "Manufacture") {
+    counts := map[string]int{"retired": 0}
 
-        return shim.Error("Incorrect role: expect Manufacture.")
+    for _, knownRole := range knownRoles {
+
+        counts[knownRole] = 0
 
     }
 
-    ComponentID := args[1]
+    for _, component := range components {
 
-    // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+        if component.Retired {
+
+            counts["retired"] = counts["retired"] + 1
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+            continue
+
+        }
+
+        // Owner is in the format of "ROLE_TYPE.ROLE_NAME", and the role
+        // check of every function is case insensitive, so is this count
+        role := strings.Split(component.Owner, ".")[0]
+
+        for _, knownRole := range knownRoles {
+
+            if strings.EqualFold(role, knownRole) {
+
+                role = knownRole
+
+            }
+
+        }
+
+        counts[role] = counts[role] + 1
 
     }
 
-    CarID := args[2]
+    countsAsBytes, _ := json.Marshal(counts)
 
-    // Recording this new car onto the blockchain
-    var car = Car{ComponentID: ComponentID}
+    fmt.Println("QueryComponentCountByRole:", string(countsAsBytes))
 
-    carAsBytes, _ := json.Marshal(car)
+    return shim.Success(countsAsBytes)
 
-    err := stub.PutState(CarID, carAsBytes)
+}
+
+
+/*
+    #############################################################
+    #############################################################
+    ############### Component Utilization Stats #################
+    #############################################################
+    #############################################################
+*/
+
+// Result of QueryComponentUtilization
+type ComponentUtilization struct {
+
+    Total       int     `json:"total"`
+
+    Mounted     int     `json:"mounted"`   // not Retired, with a CarID
+
+    Free        int     `json:"free"`      // not Retired, without a CarID
+
+    Retired     int     `json:"retired"`
+
+}
+
+/*
+
+    Count how many components are mounted, free or Retired, in a single
+    pass over all the components
+
+    Privilege:  ANYONE
+
+    Returns a JSON object of {total, mounted, free, retired}
+
+*/
+func (s *SmartContract) QueryComponentUtilization(stub shim.ChaincodeStubInterface) peer.Response {
+
+    fmt.Println("Client trying to query component utilization ...")
+
+    _, components, err := GetAllComponents(stub)
 
     if err != nil {
 
@@ -969,18 +2637,57 @@ func (s *SmartContract) CreateCar(stub shim.ChaincodeStubInterface, args []strin
 
     }
 
-    fmt.Println("Created a car", car)
+    utilization := ComponentUtilization{Total: len(components)}
+
+    for _, component := range components {
+
+        if component.Retired {
+
+            utilization.Retired = utilization.Retired + 1
+
+        } else if strings.EqualFold(component.CarID, "") {
+
+            utilization.Free = utilization.Free + 1
+
+        } else {
+
+            utilization.Mounted = utilization.Mounted + 1
+
+        }
+
+    }
+
+    utilizationAsBytes, _ := json.Marshal(utilization)
+
+    fmt.Println("QueryComponentUtilization:", string(utilizationAsBytes))
+
+    return shim.Success(utilizationAsBytes)
 
-    return shim.Success(nil)
 }
 
+
+/*
+    #############################################################
+    #############################################################
+    ################# Query Component Journey ###################
+    #############################################################
+    #############################################################
+*/
+
 /*
 
-    Query one car
-    @args[0]:   The CarID
+    Query the full journey of one component: every version in its
+    history with the Owner, the CarID it was mounted on and whether it
+    was Retired, so auditors can follow it across cars in one response.
+
+    Privilege:  ANYONE
+
+    @args[0]:   ComponentID
+
+    Returns a JSON array of {txId, timestamp, owner, carId, retired}
 
 */
-func (s *SmartContract) QueryCar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+func (s *SmartContract) QueryComponentJourney(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 
     if len(args) != 1 {
 
@@ -988,35 +2695,86 @@ func (s *SmartContract) QueryCar(stub shim.ChaincodeStubInterface, args []string
 
     }
 
-    CarID := args[0]
+    ComponentID := args[0]
 
-    fmt.Println("Client trying to query car", CarID, "...")
+    // Check component ID format
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-    // We don't need to Unmarshal because we will transfer it back to client as bytes
-    carAsBytes, err := stub.GetState(CarID)
+        return shim.Error(err.Error())
+
+    }
+
+    fmt.Println("Client trying to query journey of component", ComponentID, "...")
+
+    versions, err := GetComponentHistory(stub, ComponentID)
 
     if err != nil {
 
         return shim.Error(err.Error())
 
-    } else if len(carAsBytes) == 0 {
+    } else if len(versions) == 0 {
+
+        return shim.Error("QueryComponentJourney Error: ComponentID " + ComponentID + " not found")
 
-        return shim.Error("QueryCar Error: CarID " + CarID + " not found")
     }
 
-    fmt.Println("QueryCar:", carAsBytes)
+    versionsAsBytes, _ := json.Marshal(versions)
 
-    return shim.Success(carAsBytes)
+    fmt.Println("QueryComponentJourney:", string(versionsAsBytes))
+
+    return shim.Success(versionsAsBytes)
 
 }
 
+
 /*
+    #############################################################
+    #############################################################
+    ################# Query Component Changes ###################
+    #############################################################
+    #############################################################
+*/
 
-    Query one component by ComponentID
-    @args[0]: ComponentID
+// One changed field between two versions of a component
+type FieldChange struct {
+
+    Field       string  `json:"field"`
+
+    Old         string  `json:"old"`
+
+    New         string  `json:"new"`
+
+}
+
+// The changes made to a component by one transaction
+type ComponentChange struct {
+
+    TxId        string          `json:"txId"`
+
+    Timestamp   string          `json:"timestamp"`
+
+    Deleted     bool            `json:"deleted,omitempty"`
+
+    Changes     []FieldChange   `json:"changes"`
+
+}
+
+/*
+
+    Query what changed between consecutive versions of one component,
+    as an audit log. The first version is compared against an empty
+    component, so it lists the initial values, and transactions that
+    didn't change any field are left out.
+
+    Privilege:  ANYONE
+
+    @args[0]:   ComponentID
+
+    Returns a JSON array of {txId, timestamp, deleted, changes}, where
+    changes is an array of {field, old, new} for Owner, Retired and CarID
 
 */
-func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+func (s *SmartContract) QueryComponentChanges(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 
     if len(args) != 1 {
 
@@ -1027,38 +2785,216 @@ func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []
     ComponentID := args[0]
 
     // Check component ID format
-    if !CheckIDFormat(ComponentID) {
+    if err := checkComponentID(stub, ComponentID); err != nil {
 
-        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+        return shim.Error(err.Error())
 
     }
 
-    fmt.Println("Client trying to query component", ComponentID, "...")
+    fmt.Println("Client trying to query changes of component", ComponentID, "...")
 
-    // We don't need to Unmarshal because we will transfer it back to client as bytes
-    componentAsBytes, err := stub.GetState(ComponentID)
+    versions, err := GetComponentHistory(stub, ComponentID)
 
     if err != nil {
 
         return shim.Error(err.Error())
 
-    } else if len(ComponentID) == 0 {
+    } else if len(versions) == 0 {
 
-        return shim.Error("QueryComponent Error: ComponentID " + ComponentID + " not found")
+        return shim.Error("QueryComponentChanges Error: ComponentID " + ComponentID + " not found")
 
     }
 
-    fmt.Println("QueryComponent:", componentAsBytes)
+    changes := []ComponentChange{}
 
+    previous := ComponentVersion{}
 
-    return shim.Success(componentAsBytes)
+    for _, version := range versions {
+
+        change := ComponentChange{TxId: version.TxId, Timestamp: version.Timestamp, Changes: []FieldChange{}}
+
+        if version.Deleted {
+
+            change.Deleted = true
+
+            changes = append(changes, change)
+
+            previous = ComponentVersion{}
+
+            continue
+
+        }
+
+        if previous.Owner != version.Owner {
+
+            change.Changes = append(change.Changes, FieldChange{"Owner", previous.Owner, version.Owner})
+
+        }
+
+        if previous.Retired != version.Retired {
+
+            change.Changes = append(change.Changes, FieldChange{"Retired", strconv.FormatBool(previous.Retired), strconv.FormatBool(version.Retired)})
+
+        }
+
+        if previous.CarID != version.CarID {
+
+            change.Changes = append(change.Changes, FieldChange{"CarID", previous.CarID, version.CarID})
+
+        }
+
+        if len(change.Changes) != 0 {
+
+            changes = append(changes, change)
+
+        }
+
+        previous = version
+
+    }
+
+    changesAsBytes, _ := json.Marshal(changes)
+
+    fmt.Println("QueryComponentChanges:", string(changesAsBytes))
+
+    return shim.Success(changesAsBytes)
+
+}
+
+
+/*
+    #############################################################
+    #############################################################
+    ############ Query Components by Owner (Paged) ##############
+    #############################################################
+    #############################################################
+*/
+
+// A component together with the ComponentID it is stored under
+type ComponentRecord struct {
+
+    ComponentID string  `json:"ComponentID"`
+
+    Retired     bool    `json:"retired"`
+
+    Owner       string  `json:"Owner"`
+
+    CarID       string  `json:"carid"`
 
 }
 
+// One page of the GetComponentsByOwnerPaginated result
+type ComponentPage struct {
+
+    Components  []ComponentRecord   `json:"components"`
+
+    Bookmark    string              `json:"bookmark"`
+
+}
 
 /*
-    TODO: Helper function to query all components
+
+    Query the components of one Owner page by page, for Owners with
+    large inventories.
+
+    The page is fetched with GetStateByRangeWithPagination and filtered
+    by Owner afterwards, so a page may hold fewer than pageSize matches
+    (or none at all). Clients must keep paging with the returned bookmark
+    until it is empty. Like every paginated query in Fabric, this only
+    works as a query, not in an invoke transaction.
+
+    Privilege:  ANYONE
+
+    @args[0]:   Owner, e.g. "Dealer.d0"
+    @args[1]:   pageSize
+    @args[2]:   (optional) bookmark returned by the previous page
+
+    Returns a JSON object of {components, bookmark}
+
 */
+func (s *SmartContract) GetComponentsByOwnerPaginated(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 2 && len(args) != 3 {
+
+        return shim.Error("Incorrect number of arguments, expecting 2 or 3")
+
+    }
+
+    owner := args[0]
+
+    // pageSize is passed to the ledger as an int32, so it must fit in one
+    pageSize, err := strconv.ParseInt(args[1], 10, 32)
+
+    if err != nil || pageSize <= 0 {
+
+        return shim.Error("Incorrect pageSize: expect a positive 32-bit integer.")
+
+    }
+
+    bookmark := ""
+
+    if len(args) == 3 {
+
+        bookmark = args[2]
+
+    }
+
+    fmt.Println("Client trying to query components of", owner, "with pageSize", pageSize, "...")
+
+    resultsIterator, metadata, err := stub.GetStateByRangeWithPagination("", "", int32(pageSize), bookmark)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    defer resultsIterator.Close()
+
+    IDLength, err := GetIDLength(stub)
+
+    if err != nil {
+
+        return shim.Error(err.Error())
+
+    }
+
+    page := ComponentPage{Components: []ComponentRecord{}, Bookmark: metadata.Bookmark}
+
+    for resultsIterator.HasNext() {
+
+        queryResponse, err := resultsIterator.Next()
+
+        if err != nil {
+
+            return shim.Error(err.Error())
+
+        }
+
+        // Skip cars and other non-component entries
+        component, ok := componentFromKV(queryResponse.Key, queryResponse.Value, IDLength)
+
+        if !ok {
+
+            continue
+
+        }
+
+        if strings.EqualFold(component.Owner, owner) {
+
+            page.Components = append(page.Components, ComponentRecord{queryResponse.Key, component.Retired, component.Owner, component.CarID})
+
+        }
+
+    }
+
+    pageAsBytes, _ := json.Marshal(page)
+
+    fmt.Println("GetComponentsByOwnerPaginated:", string(pageAsBytes))
+
+    return shim.Success(pageAsBytes)
+
+}
 
 
 /*
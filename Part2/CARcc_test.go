@@ -0,0 +1,725 @@
+/*
+    Author:           Jason You All Rights Reserved
+    Project:          Car Components Supply Chain
+
+    SPDX-License-Identifier: Apache-2.0
+
+    Tests of the car component chaincode, run against the MockStub
+    provided by the shim package.                                   */
+
+
+
+package main
+
+import (
+
+    "bytes"
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/golang/protobuf/ptypes/timestamp"
+    "github.com/hyperledger/fabric/core/chaincode/shim"
+    "github.com/hyperledger/fabric/protos/ledger/queryresult"
+
+)
+
+// Invoke the chaincode with the function name and string arguments
+func invoke(stub *shim.MockStub, args ...string) (int32, string) {
+
+    byteArgs := [][]byte{}
+
+    for _, arg := range args {
+
+        byteArgs = append(byteArgs, []byte(arg))
+
+    }
+
+    response := stub.MockInvoke("tx", byteArgs)
+
+    return response.Status, response.Message
+
+}
+
+func TestQueryComponentExisting(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if status, message := invoke(stub, "AddComponent", "Supplier.s0", "000000001"); status != shim.OK {
+
+        t.Fatalf("AddComponent failed: %s", message)
+
+    }
+
+    if status, message := invoke(stub, "QueryComponent", "000000001"); status != shim.OK {
+
+        t.Fatalf("QueryComponent of an existing component failed: %s", message)
+
+    }
+
+}
+
+func TestQueryComponentNotFound(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if status, _ := invoke(stub, "QueryComponent", "000000099"); status == shim.OK {
+
+        t.Fatal("QueryComponent of a non-existent ComponentID should return an error")
+
+    }
+
+}
+
+// MockStub reads see the writes made earlier in the same transaction, but a
+// peer reads the state as of the start of the transaction. This stub keeps
+// the peer behavior by reading from a copy taken before the transaction.
+type committedReadStub struct {
+
+    *shim.MockStub
+
+    committed   map[string][]byte
+
+}
+
+func (stub *committedReadStub) GetState(key string) ([]byte, error) {
+
+    return stub.committed[key], nil
+
+}
+
+func TestMigrateComponentIDsCollision(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    // Both malformed keys pad to the same ComponentID "000012345"
+    stub.MockTransactionStart("seed")
+
+    stub.PutState("0012345",    []byte(`{"retired":false,"Owner":"Supplier.s0","carid":""}`))
+
+    stub.PutState("12345",      []byte(`{"retired":false,"Owner":"Supplier.s1","carid":""}`))
+
+    stub.MockTransactionEnd("seed")
+
+    // Call the function directly, so it sees the peer read behavior
+    stub.MockTransactionStart("tx")
+
+    response := new(SmartContract).MigrateComponentIDs(&committedReadStub{stub, copyState(stub)}, []string{"Manufacture.m0"})
+
+    stub.MockTransactionEnd("tx")
+
+    if response.Status != shim.OK {
+
+        t.Fatalf("MigrateComponentIDs failed: %s", response.Message)
+
+    }
+
+    report := MigrationReport{}
+
+    json.Unmarshal(response.Payload, &report)
+
+    if report.Migrated != 1 || len(report.Skipped) != 1 || report.Skipped[0] != "12345" {
+
+        t.Fatalf("Expect 1 migrated and 12345 skipped, got %+v", report)
+
+    }
+
+    // The skipped record must still be there, under its old key
+    if stub.State["000012345"] == nil || stub.State["12345"] == nil || stub.State["0012345"] != nil {
+
+        t.Fatal("Expect 000012345 written, 12345 kept and 0012345 deleted")
+
+    }
+
+}
+
+func TestMigrateComponentIDsRepointsCar(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    // The car points to the malformed key, but the component doesn't
+    // record the car, as left by CreateCar or RecallComponent
+    stub.MockTransactionStart("seed")
+
+    stub.PutState("12345",      []byte(`{"retired":false,"Owner":"Manufacture.m0","carid":""}`))
+
+    stub.PutState("CAR0",       []byte(`{"ComponentID":"12345"}`))
+
+    stub.MockTransactionEnd("seed")
+
+    if status, message := invoke(stub, "MigrateComponentIDs", "Manufacture.m0"); status != shim.OK {
+
+        t.Fatalf("MigrateComponentIDs failed: %s", message)
+
+    }
+
+    car := Car{}
+
+    json.Unmarshal(stub.State["CAR0"], &car)
+
+    if car.ComponentID != "000012345" {
+
+        t.Fatalf("Expect CAR0 to point to 000012345, got %s", car.ComponentID)
+
+    }
+
+}
+
+// Seed a car CAR0 mounted with 000000001, plus a recalled (Retired and
+// unmounted) component 000000002
+func seedReplacement(t *testing.T) *shim.MockStub {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    steps := [][]string{
+
+        {"AddComponent", "Supplier.s0", "000000001"},
+
+        {"AddComponent", "Supplier.s0", "000000002"},
+
+        {"MountComponent", "Manufacture.m0", "000000001", "CAR0"},
+
+        {"RecallComponent", "Manufacture.m0", "000000002"},
+
+    }
+
+    for _, step := range steps {
+
+        if status, message := invoke(stub, step...); status != shim.OK {
+
+            t.Fatalf("%s failed: %s", step[0], message)
+
+        }
+
+    }
+
+    return stub
+
+}
+
+// Copy the world state, to check later that nothing was written
+func copyState(stub *shim.MockStub) map[string][]byte {
+
+    state := map[string][]byte{}
+
+    for key, value := range stub.State {
+
+        state[key] = value
+
+    }
+
+    return state
+
+}
+
+func assertStateUnchanged(t *testing.T, stub *shim.MockStub, before map[string][]byte) {
+
+    if len(stub.State) != len(before) {
+
+        t.Fatalf("Expect %d keys in the world state, got %d", len(before), len(stub.State))
+
+    }
+
+    for key, value := range before {
+
+        if !bytes.Equal(stub.State[key], value) {
+
+            t.Fatalf("Key %s changed from %s to %s", key, value, stub.State[key])
+
+        }
+
+    }
+
+}
+
+func TestGetComponentStatusOnCarKey(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    // CreateCar accepts any CarID, including a digit string
+    if status, message := invoke(stub, "CreateCar", "Manufacture.m0", "000000001", "000000009"); status != shim.OK {
+
+        t.Fatalf("CreateCar failed: %s", message)
+
+    }
+
+    if status, _ := invoke(stub, "GetComponentStatus", "000000009"); status == shim.OK {
+
+        t.Fatal("GetComponentStatus of a car key should return an error")
+
+    }
+
+}
+
+func TestVerifyComponentValidityOnCarKey(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if status, message := invoke(stub, "CreateCar", "Manufacture.m0", "000000001", "000000009"); status != shim.OK {
+
+        t.Fatalf("CreateCar failed: %s", message)
+
+    }
+
+    response := stub.MockInvoke("tx", [][]byte{[]byte("VerifyComponentValidity"), []byte("000000009")})
+
+    validity := ComponentValidity{}
+
+    json.Unmarshal(response.Payload, &validity)
+
+    if response.Status != shim.OK || validity.Valid || validity.Reason != "The given component is not found." {
+
+        t.Fatalf("Expect a car key to be reported as not found, got %d %s", response.Status, response.Payload)
+
+    }
+
+}
+
+func TestReplaceComponentRetiredReplacement(t *testing.T) {
+
+    stub := seedReplacement(t)
+
+    before := copyState(stub)
+
+    if status, _ := invoke(stub, "ReplaceComponent", "Manufacture.m0", "000000002", "CAR0"); status == shim.OK {
+
+        t.Fatal("ReplaceComponent with a Retired replacement should return an error")
+
+    }
+
+    assertStateUnchanged(t, stub, before)
+
+}
+
+func TestReplaceComponentMissingReplacement(t *testing.T) {
+
+    stub := seedReplacement(t)
+
+    before := copyState(stub)
+
+    if status, _ := invoke(stub, "ReplaceComponent", "Manufacture.m0", "000000099", "CAR0"); status == shim.OK {
+
+        t.Fatal("ReplaceComponent with a non-existent replacement should return an error")
+
+    }
+
+    assertStateUnchanged(t, stub, before)
+
+}
+
+func TestIDLengthSurvivesCarNamedIDLength(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if response := stub.MockInit("init", [][]byte{[]byte("init"), []byte("12")}); response.Status != shim.OK {
+
+        t.Fatalf("Init failed: %s", response.Message)
+
+    }
+
+    // A car written under the plain key "IDLength" must not touch the setting
+    if status, message := invoke(stub, "CreateCar", "Manufacture.m0", "000000000001", "IDLength"); status != shim.OK {
+
+        t.Fatalf("CreateCar failed: %s", message)
+
+    }
+
+    if status, message := invoke(stub, "AddComponent", "Supplier.s0", "000000000002"); status != shim.OK {
+
+        t.Fatalf("AddComponent of a 12-digit ComponentID failed: %s", message)
+
+    }
+
+    if status, _ := invoke(stub, "AddComponent", "Supplier.s0", "000000003"); status == shim.OK {
+
+        t.Fatal("AddComponent of a 9-digit ComponentID should fail with the 12-digit length")
+
+    }
+
+}
+
+// Store a car whose mounted component record is missing
+func seedCarWithoutComponent(t *testing.T) *shim.MockStub {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    stub.MockTransactionStart("seed")
+
+    stub.PutState("CAR9",       []byte(`{"ComponentID":"000000077"}`))
+
+    stub.MockTransactionEnd("seed")
+
+    return stub
+
+}
+
+func TestReadCarResolveMissingComponent(t *testing.T) {
+
+    stub := seedCarWithoutComponent(t)
+
+    response := stub.MockInvoke("tx", [][]byte{[]byte("ReadCar"), []byte("CAR9"), []byte("true")})
+
+    if response.Status != shim.OK {
+
+        t.Fatalf("ReadCar failed: %s", response.Message)
+
+    }
+
+    view := map[string]json.RawMessage{}
+
+    json.Unmarshal(response.Payload, &view)
+
+    if string(view["components"]) != "[]" {
+
+        t.Fatalf("Expect an empty components array when resolving, got %s", response.Payload)
+
+    }
+
+}
+
+func TestReadCarWithoutResolve(t *testing.T) {
+
+    stub := seedCarWithoutComponent(t)
+
+    response := stub.MockInvoke("tx", [][]byte{[]byte("ReadCar"), []byte("CAR9")})
+
+    if response.Status != shim.OK {
+
+        t.Fatalf("ReadCar failed: %s", response.Message)
+
+    }
+
+    view := map[string]json.RawMessage{}
+
+    json.Unmarshal(response.Payload, &view)
+
+    if _, ok := view["components"]; ok {
+
+        t.Fatalf("Expect no components key unless resolving, got %s", response.Payload)
+
+    }
+
+}
+
+func TestReadCarOnComponentKey(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if status, message := invoke(stub, "AddComponent", "Supplier.s0", "000000001"); status != shim.OK {
+
+        t.Fatalf("AddComponent failed: %s", message)
+
+    }
+
+    if status, _ := invoke(stub, "ReadCar", "000000001", "true"); status == shim.OK {
+
+        t.Fatal("ReadCar of a component key should return an error")
+
+    }
+
+}
+
+func TestInitLengthRefusedWithComponents(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if response := stub.MockInit("init", [][]byte{[]byte("init")}); response.Status != shim.OK {
+
+        t.Fatalf("Init failed: %s", response.Message)
+
+    }
+
+    if status, message := invoke(stub, "AddComponent", "Supplier.s0", "000000001"); status != shim.OK {
+
+        t.Fatalf("AddComponent failed: %s", message)
+
+    }
+
+    // Upgrading to another length would strand the 9-digit component
+    if response := stub.MockInit("upgrade", [][]byte{[]byte("init"), []byte("12")}); response.Status == shim.OK {
+
+        t.Fatal("Init with a new ID length should fail once components exist")
+
+    }
+
+    if status, message := invoke(stub, "QueryComponent", "000000001"); status != shim.OK {
+
+        t.Fatalf("QueryComponent after the refused upgrade failed: %s", message)
+
+    }
+
+}
+
+func TestInitTooManyArgs(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if response := stub.MockInit("init", [][]byte{[]byte("init"), []byte("12"), []byte("9")}); response.Status == shim.OK {
+
+        t.Fatal("Init with more than one argument should return an error")
+
+    }
+
+}
+
+func TestQueryComponentRangeInclusiveEnd(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    component := []byte(`{"retired":false,"Owner":"Supplier.s0","carid":""}`)
+
+    // One component below, at the start, inside, at the end and above
+    // the range, plus a car stored under a digit CarID inside it
+    stub.MockTransactionStart("seed")
+
+    for _, ComponentID := range []string{"000000001", "000000002", "000000003", "000000005", "000000006"} {
+
+        stub.PutState(ComponentID, component)
+
+    }
+
+    stub.PutState("000000004",  []byte(`{"ComponentID":"000000003"}`))
+
+    stub.MockTransactionEnd("seed")
+
+    response := stub.MockInvoke("tx", [][]byte{[]byte("QueryComponentRange"), []byte("000000002"), []byte("000000005")})
+
+    if response.Status != shim.OK {
+
+        t.Fatalf("QueryComponentRange failed: %s", response.Message)
+
+    }
+
+    records := []ComponentRecord{}
+
+    json.Unmarshal(response.Payload, &records)
+
+    ComponentIDs := []string{}
+
+    for _, record := range records {
+
+        ComponentIDs = append(ComponentIDs, record.ComponentID)
+
+    }
+
+    if len(ComponentIDs) != 3 || ComponentIDs[0] != "000000002" || ComponentIDs[1] != "000000003" || ComponentIDs[2] != "000000005" {
+
+        t.Fatalf("Expect 000000002, 000000003 and 000000005, got %v", ComponentIDs)
+
+    }
+
+}
+
+func TestAddComponentsBatchUsedID(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    if status, message := invoke(stub, "AddComponent", "Supplier.s0", "000000003"); status != shim.OK {
+
+        t.Fatalf("AddComponent failed: %s", message)
+
+    }
+
+    before := copyState(stub)
+
+    status, message := invoke(stub, "AddComponentsBatch", "Supplier.s0", `["000000001","000000002","000000003"]`)
+
+    if status == shim.OK || !strings.Contains(message, "000000003") {
+
+        t.Fatalf("AddComponentsBatch with a used ComponentID should fail naming it, got %d %s", status, message)
+
+    }
+
+    assertStateUnchanged(t, stub, before)
+
+}
+
+func TestAddComponentsBatchRepeatedID(t *testing.T) {
+
+    stub := shim.NewMockStub("CARcc", new(SmartContract))
+
+    before := copyState(stub)
+
+    status, message := invoke(stub, "AddComponentsBatch", "Supplier.s0", `["000000001","000000002","000000001"]`)
+
+    if status == shim.OK || !strings.Contains(message, "000000001") {
+
+        t.Fatalf("AddComponentsBatch with a repeated ComponentID should fail naming it, got %d %s", status, message)
+
+    }
+
+    assertStateUnchanged(t, stub, before)
+
+}
+
+// MockStub doesn't implement GetHistoryForKey, so this stub returns a
+// fixed history for each key instead
+type historyStub struct {
+
+    *shim.MockStub
+
+    history     map[string][]*queryresult.KeyModification
+
+}
+
+type historyIterator struct {
+
+    modifications   []*queryresult.KeyModification
+
+    next            int
+
+}
+
+func (iterator *historyIterator) HasNext() bool {
+
+    return iterator.next < len(iterator.modifications)
+
+}
+
+func (iterator *historyIterator) Next() (*queryresult.KeyModification, error) {
+
+    iterator.next = iterator.next + 1
+
+    return iterator.modifications[iterator.next - 1], nil
+
+}
+
+func (iterator *historyIterator) Close() error {
+
+    return nil
+
+}
+
+func (stub *historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+
+    return &historyIterator{modifications: stub.history[key]}, nil
+
+}
+
+// One version in a fixed history, written at the given second
+func modification(txID string, seconds int64, value string) *queryresult.KeyModification {
+
+    if value == "" {
+
+        return &queryresult.KeyModification{TxId: txID, Timestamp: &timestamp.Timestamp{Seconds: seconds}, IsDelete: true}
+
+    }
+
+    return &queryresult.KeyModification{TxId: txID, Timestamp: &timestamp.Timestamp{Seconds: seconds}, Value: []byte(value)}
+
+}
+
+func TestQueryComponentChanges(t *testing.T) {
+
+    stub := &historyStub{shim.NewMockStub("CARcc", new(SmartContract)), map[string][]*queryresult.KeyModification{
+
+        "000000001": {
+
+            modification("tx1", 1, `{"retired":false,"Owner":"Supplier.s0","carid":""}`),
+
+            // Nothing changed, so it is left out
+            modification("tx2", 2, `{"retired":false,"Owner":"Supplier.s0","carid":""}`),
+
+            modification("tx3", 3, `{"retired":false,"Owner":"Manufacture.m0","carid":"CAR0"}`),
+
+            modification("tx4", 4, ""),
+
+            // Compared against an empty component again after the delete
+            modification("tx5", 5, `{"retired":false,"Owner":"Manufacture.m0","carid":"CAR0"}`),
+
+            modification("tx6", 6, `{"retired":true,"Owner":"Manufacture.m0","carid":""}`),
+
+        },
+
+    }}
+
+    response := new(SmartContract).QueryComponentChanges(stub, []string{"000000001"})
+
+    if response.Status != shim.OK {
+
+        t.Fatalf("QueryComponentChanges failed: %s", response.Message)
+
+    }
+
+    changes := []ComponentChange{}
+
+    json.Unmarshal(response.Payload, &changes)
+
+    expected := []ComponentChange{
+
+        {"tx1", "1970-01-01T00:00:01Z", false, []FieldChange{{"Owner", "", "Supplier.s0"}}},
+
+        {"tx3", "1970-01-01T00:00:03Z", false, []FieldChange{{"Owner", "Supplier.s0", "Manufacture.m0"}, {"CarID", "", "CAR0"}}},
+
+        {"tx4", "1970-01-01T00:00:04Z", true, []FieldChange{}},
+
+        {"tx5", "1970-01-01T00:00:05Z", false, []FieldChange{{"Owner", "", "Manufacture.m0"}, {"CarID", "", "CAR0"}}},
+
+        {"tx6", "1970-01-01T00:00:06Z", false, []FieldChange{{"Retired", "false", "true"}, {"CarID", "CAR0", ""}}},
+
+    }
+
+    if !reflect.DeepEqual(changes, expected) {
+
+        t.Fatalf("Expect %+v, got %+v", expected, changes)
+
+    }
+
+}
+
+func TestQueryComponentsByCarIDReplaced(t *testing.T) {
+
+    // 000000001 was replaced on CAR0 by 000000002, so it is Retired with
+    // an empty CarID and only the history of CAR0 still records it
+    stub := &historyStub{shim.NewMockStub("CARcc", new(SmartContract)), map[string][]*queryresult.KeyModification{
+
+        "CAR0": {
+
+            modification("tx1", 1, `{"ComponentID":"000000001"}`),
+
+            modification("tx2", 2, `{"ComponentID":"000000002"}`),
+
+        },
+
+    }}
+
+    stub.MockTransactionStart("seed")
+
+    stub.PutState("000000001",  []byte(`{"retired":true,"Owner":"Manufacture.m0","carid":""}`))
+
+    stub.PutState("000000002",  []byte(`{"retired":false,"Owner":"Manufacture.m0","carid":"CAR0"}`))
+
+    stub.PutState("000000003",  []byte(`{"retired":false,"Owner":"Manufacture.m0","carid":"CAR1"}`))
+
+    stub.PutState("CAR0",       []byte(`{"ComponentID":"000000002"}`))
+
+    stub.MockTransactionEnd("seed")
+
+    response := new(SmartContract).QueryComponentsByCarID(stub, []string{"CAR0"})
+
+    if response.Status != shim.OK {
+
+        t.Fatalf("QueryComponentsByCarID failed: %s", response.Message)
+
+    }
+
+    records := []ComponentRecord{}
+
+    json.Unmarshal(response.Payload, &records)
+
+    expected := []ComponentRecord{
+
+        {"000000002", false, "Manufacture.m0", "CAR0"},
+
+        {"000000001", true, "Manufacture.m0", ""},
+
+    }
+
+    if !reflect.DeepEqual(records, expected) {
+
+        t.Fatalf("Expect %+v, got %+v", expected, records)
+
+    }
+
+}
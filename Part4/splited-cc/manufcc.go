@@ -85,6 +85,8 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
         return s.QueryCar(stub, args)
     } else if fn == "QueryComponent" {
         return s.QueryComponent(stub, args)
+    } else if fn == "QueryComponentByCarID" {
+        return s.QueryComponentByCarID(stub, args)
     }
 
     return shim.Error("Invalid Smart Contract function name.")
@@ -310,15 +312,24 @@ func (s *SmartContract) ReplaceComponent(stub shim.ChaincodeStubInterface, args
     json.Unmarshal(carAsBytes, &car)
 
 
-    // Check if component already Retired
+    // Note: component is the new one, which replaces the old one on the car
+
+    // Check if the replacement component exists, otherwise it would be
+    // decoded as a fresh, non-Retired component and pass the checks below
+    if len(componentAsBytes) == 0 {
+        return shim.Error("The given replacement component is not found.")
+    }
+
+    // Check if the replacement component already Retired: a Retired
+    // component must never be mounted again
     if component.Retired {
-        return shim.Error("The given component is already Retired.")
+        return shim.Error("The given replacement component is already Retired.")
     }
 
     // Check if component already mounted
     if !strings.EqualFold(component.CarID, "") {
         return shim.Error("The given component is already mounted.")
-    }   // note: component is the new one
+    }
 
     // Check if this car is properly mounted with some comonent
     if strings.EqualFold(car.ComponentID, "") {
@@ -332,7 +343,6 @@ func (s *SmartContract) ReplaceComponent(stub shim.ChaincodeStubInterface, args
     json.Unmarshal(oldComponentAsBytes, &oldComponent)
 
     // Update the information of the new component and the car
-    component.Retired       = false
     component.Owner         = oldComponent.Owner
     component.CarID         = CarID
     car.ComponentID         = ComponentID
@@ -511,7 +521,7 @@ func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []
 
     if err != nil {
         return shim.Error(err.Error())
-    } else if len(ComponentID) == 0 {
+    } else if len(componentAsBytes) == 0 {
         return shim.Error("QueryComponent Error: ComponentID " + ComponentID + " not found")
     }
 
@@ -521,6 +531,49 @@ func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []
     return shim.Success(componentAsBytes)
 }
 
+/*
+    Query the component currently mounted on a car
+    @args[0]: CarID
+*/
+func (s *SmartContract) QueryComponentByCarID(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+        return shim.Error("Incorrect number of arguments, expecting 1")
+    }
+
+    CarID := args[0]
+    fmt.Println("Client trying to query the component on car", CarID, "...")
+
+    // Get the car first to find out which component is mounted on it
+    carAsBytes, err := stub.GetState(CarID)
+
+    if err != nil {
+        return shim.Error(err.Error())
+    } else if len(carAsBytes) == 0 {
+        return shim.Error("QueryComponentByCarID Error: CarID " + CarID + " not found")
+    }
+
+    car := Car{}
+    json.Unmarshal(carAsBytes, &car)
+
+    if strings.EqualFold(car.ComponentID, "") {
+        return shim.Error("QueryComponentByCarID Error: CarID " + CarID + " has no component mounted")
+    }
+
+    // We don't need to Unmarshal because we will transfer it back to client as bytes
+    componentAsBytes, err := stub.GetState(car.ComponentID)
+
+    if err != nil {
+        return shim.Error(err.Error())
+    } else if len(componentAsBytes) == 0 {
+        return shim.Error("QueryComponentByCarID Error: ComponentID " + car.ComponentID + " not found")
+    }
+
+    fmt.Println("QueryComponentByCarID:", componentAsBytes)
+
+    return shim.Success(componentAsBytes)
+}
+
 
 func main() {
     // Create a new 
@@ -291,7 +291,7 @@ func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []
 
     if err != nil {
         return shim.Error(err.Error())
-    } else if len(ComponentID) == 0 {
+    } else if len(componentAsBytes) == 0 {
         return shim.Error("QueryComponent Error: ComponentID " + ComponentID + " not found")
     }
 
@@ -21,6 +21,7 @@ import (
     "fmt"
     "strconv"
     "strings"
+    "time"
     // "errors"
 
     "github.com/hyperledger/fabric/core/chaincode/shim"
@@ -46,6 +47,14 @@ type CarComponent struct {
     CarID       string  `json:"carid"`
 }
 
+// One Owner in the ownership chain of a component
+// The JSON tags match the history views of CARcc (ComponentVersion)
+type OwnershipRecord struct {
+    Owner       string  `json:"owner"`
+    TxId        string  `json:"txId"`
+    Timestamp   string  `json:"timestamp"`
+}
+
 // Car that stores the ComponentID mounted on it
 // We only record one component for convinence,
 // but we can use veracity string if we want
@@ -87,6 +96,8 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
         return s.QueryCar(stub, args)
     } else if fn == "QueryComponent" {
         return s.QueryComponent(stub, args)
+    } else if fn == "QueryOwnershipChain" {
+        return s.QueryOwnershipChain(stub, args)
     }
 
     return shim.Error("Invalid Smart Contract function name.")
@@ -292,7 +303,7 @@ func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []
 
     if err != nil {
         return shim.Error(err.Error())
-    } else if len(ComponentID) == 0 {
+    } else if len(componentAsBytes) == 0 {
         return shim.Error("QueryComponent Error: ComponentID " + ComponentID + " not found")
     }
 
@@ -302,6 +313,65 @@ func (s *SmartContract) QueryComponent(stub shim.ChaincodeStubInterface, args []
     return shim.Success(componentAsBytes)
 }
 
+/*
+    Query the ownership chain of one component, from the first Owner to
+    the current one, with the transaction and time of every transfer
+    @args[0]: ComponentID
+*/
+func (s *SmartContract) QueryOwnershipChain(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+    if len(args) != 1 {
+        return shim.Error("Incorrect number of arguments, expecting 1")
+    }
+
+    ComponentID := args[0]
+
+    // Check component ID format
+    if !CheckIDFormat(ComponentID) {
+        return shim.Error("Incorrect ComponentID format: expect 9-digit string")
+    }
+
+    fmt.Println("Client trying to query ownership chain of component", ComponentID, "...")
+
+    historyIterator, err := stub.GetHistoryForKey(ComponentID)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    defer historyIterator.Close()
+
+    // Only record a version when the Owner is different from the last one,
+    // since mounting or retiring a component keeps the same Owner
+    chain := []OwnershipRecord{}
+    for historyIterator.HasNext() {
+        modification, err := historyIterator.Next()
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        if modification.IsDelete {
+            continue
+        }
+
+        component := CarComponent{}
+        json.Unmarshal(modification.Value, &component)
+
+        if len(chain) > 0 && chain[len(chain) - 1].Owner == component.Owner {
+            continue
+        }
+
+        timestamp := time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+        chain = append(chain, OwnershipRecord{component.Owner, modification.TxId, timestamp.Format(time.RFC3339)})
+    }
+
+    if len(chain) == 0 {
+        return shim.Error("QueryOwnershipChain Error: ComponentID " + ComponentID + " not found")
+    }
+
+    chainAsBytes, _ := json.Marshal(chain)
+    fmt.Println("QueryOwnershipChain:", string(chainAsBytes))
+
+    return shim.Success(chainAsBytes)
+}
+
 
 func main() {
     // Create a new 